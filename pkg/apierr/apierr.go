@@ -0,0 +1,98 @@
+// Package apierr defines typed, machine-readable API errors so
+// clients can render inline form validation and localized error
+// strings without string-matching English error messages.
+package apierr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes returned by the chat API, grouped by the domain they
+// belong to.
+const (
+	// 1000s: nick-related errors.
+	NickRequired     = 1000
+	NickTooShort     = 1001
+	NickInvalidChars = 1002
+	FieldTooLong     = 1003
+
+	// 1100s: channel-related errors.
+	ChannelRequired     = 1100
+	ChannelNameInvalid  = 1101
+	ChannelNotFound     = 1102
+	ChannelCreateFailed = 1103
+
+	// 1300s: secret-related errors.
+	InvalidSecret  = 1301
+	SecretTooShort = 1302
+
+	// 1400s: pagination/history-related errors.
+	InvalidCursor      = 1401
+	InvalidLimit       = 1402
+	InvalidDirection   = 1403
+	HistoryUnavailable = 1404
+
+	// 1500s: webhook ingress errors.
+	WebhookRateLimited     = 1501
+	WebhookFormatUnknown   = 1502
+	WebhookBodyUnreadable  = 1503
+	WebhookPayloadTooLarge = 1504
+	WebhookMalformed       = 1505
+	WebhookPublishFailed   = 1506
+
+	// 1600s: chat message errors.
+	MessageRequired      = 1601
+	MessagePublishFailed = 1602
+)
+
+// APIError is a typed, machine-readable error returned by the chat
+// API. Code is stable across releases so clients can switch on it;
+// Highlight names the offending JSON field, if any, so clients can
+// render inline validation next to the right form field.
+type APIError struct {
+	Code       int
+	HTTPStatus int
+	Message    string
+	Highlight  string
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+// New creates an APIError.
+func New(code, httpStatus int, highlight, message string) *APIError {
+	return &APIError{Code: code, HTTPStatus: httpStatus, Message: message, Highlight: highlight}
+}
+
+// BadRequest creates an APIError with a 400 status, the common case
+// for request validation failures.
+func BadRequest(code int, highlight, message string) *APIError {
+	return New(code, http.StatusBadRequest, highlight, message)
+}
+
+// wireError is the JSON envelope returned to clients for a failed
+// request.
+type wireError struct {
+	Success      bool   `json:"success"`
+	Error        int    `json:"error"`
+	ErrHighlight string `json:"errhighlight,omitempty"`
+	Message      string `json:"message"`
+}
+
+// WriteJSON writes err to w as a JSON error envelope using its
+// HTTPStatus (defaulting to 400 if unset).
+func WriteJSON(w http.ResponseWriter, err *APIError) {
+	status := err.HTTPStatus
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(wireError{
+		Success:      false,
+		Error:        err.Code,
+		ErrHighlight: err.Highlight,
+		Message:      err.Message,
+	})
+}
@@ -8,6 +8,10 @@ import (
 
 	h "github.com/tonto/kit/http"
 	"github.com/tonto/kit/http/respond"
+
+	"github.com/tonto/gossip/pkg/apierr"
+	"github.com/tonto/gossip/pkg/broker"
+	"github.com/tonto/gossip/pkg/pow"
 )
 
 const (
@@ -21,12 +25,29 @@ const (
 	minChanNameLen   = 3
 	maxChanNameLen   = 25
 	maxChanSecretLen = 64
+
+	// nickPoWDifficulty is the number of leading zero bits a
+	// /register_nick proof-of-work solution must have.
+	nickPoWDifficulty = 18
 )
 
-// NewAPI creates new websocket api
-func NewAPI(store Store, admin, password string) *API {
+// NewAPI creates new websocket api. pub is used to publish normalized
+// webhook payloads onto a channel's ingest subject. pepper keys the
+// HMAC used to derive user ids and should be a long, random,
+// server-side value loaded once at startup. opts can override defaults
+// such as the webhook payload size cap; see WithWebhookMaxBytes.
+func NewAPI(store Store, pub Publisher, admin, password, pepper string, opts ...Option) *API {
 	api := API{
-		store: store,
+		store:           store,
+		pub:             pub,
+		pow:             pow.NewManager(0),
+		userIDs:         NewUserIDCalculator(pepper),
+		webhookLimiter:  newWebhookLimiter(),
+		webhookMaxBytes: defWebhookMaxBytes,
+	}
+
+	for _, opt := range opts {
+		opt(&api)
 	}
 
 	api.RegisterEndpoint(
@@ -44,16 +65,42 @@ func NewAPI(store Store, admin, password string) *API {
 	)
 
 	api.RegisterHandler("GET", "/list_channels", api.listChannels)
-	api.RegisterEndpoint("POST", "/register_nick", api.registerNick)
+	api.RegisterHandler("GET", "/pow/challenge", powChallengeHandler(api.pow, nickPoWDifficulty))
+	api.RegisterEndpoint(
+		"POST",
+		"/register_nick",
+		api.registerNick,
+		WithPoW(api.pow, nickPoWDifficulty),
+	)
 	api.RegisterEndpoint("POST", "/channel_members", api.channelMembers)
+	api.RegisterEndpoint("POST", "/channel_history", api.channelHistory)
+	api.RegisterEndpoint("POST", "/rotate_nick_secret", api.rotateNickSecret)
+	api.RegisterEndpoint("POST", "/send_message", api.sendMessage)
+	api.RegisterHandler("POST", "/channel/:name/webhook", api.channelWebhook)
 
 	return &api
 }
 
+// Option configures an API instance.
+type Option func(*API)
+
+// WithWebhookMaxBytes overrides the maximum accepted webhook payload
+// size in bytes (defWebhookMaxBytes otherwise), so operators can raise
+// or lower the cap without editing source.
+func WithWebhookMaxBytes(n int64) Option {
+	return func(api *API) { api.webhookMaxBytes = n }
+}
+
 // API represents websocket api service
 type API struct {
 	h.BaseService
-	store Store
+	store   Store
+	pub     Publisher
+	pow     *pow.Manager
+	userIDs *UserIDCalculator
+
+	webhookLimiter  *webhookLimiter
+	webhookMaxBytes int64
 }
 
 // Store represents chat store interface
@@ -62,6 +109,7 @@ type Store interface {
 	Get(string) (*Chat, error)
 	ListChannels() ([]string, error)
 	GetUnreadCount(string, string) uint64
+	History(channel string, opts HistoryOpts) (nextCursor, prevCursor string, msgs []broker.Msg, err error)
 }
 
 // Prefix returns api prefix for this service
@@ -78,21 +126,26 @@ type createChanResp struct {
 
 func (cr *createChanReq) Validate() error {
 	if cr.Name == "" {
-		return fmt.Errorf("name must not be empty")
+		return apierr.BadRequest(apierr.ChannelNameInvalid, "name", "name must not be empty")
 	}
 	if len(cr.Name) < minChanNameLen || len(cr.Name) > maxChanNameLen {
-		return fmt.Errorf("name must be between %d and %d characters long", minChanNameLen, maxChanNameLen)
+		return apierr.BadRequest(apierr.ChannelNameInvalid, "name", fmt.Sprintf("name must be between %d and %d characters long", minChanNameLen, maxChanNameLen))
 	}
 	if match, err := regexp.Match("^[a-zA-Z0-9_]*$", []byte(cr.Name)); !match || err != nil {
-		return fmt.Errorf("name must contain only alphanumeric and underscores")
+		return apierr.BadRequest(apierr.ChannelNameInvalid, "name", "name must contain only alphanumeric and underscores")
 	}
 	return nil
 }
 
 func (api *API) createChannel(c context.Context, w http.ResponseWriter, req *createChanReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
 	ch := NewChannel(req.Name, req.Private)
 	if err := api.store.Save(ch); err != nil {
-		return nil, fmt.Errorf("could not create channel at this moment")
+		apierr.WriteJSON(w, apierr.New(apierr.ChannelCreateFailed, http.StatusInternalServerError, "", "could not create channel at this moment"))
+		return nil, nil
 	}
 	return h.NewResponse(createChanResp{Secret: ch.Secret}, http.StatusOK), nil
 }
@@ -108,44 +161,54 @@ type registerNickReq struct {
 
 type registerNickResp struct {
 	Secret string `json:"secret"`
+	UserID string `json:"user_id"`
 }
 
 func (r *registerNickReq) Validate() error {
 	if r.Nick == "" {
-		return fmt.Errorf("nick is required")
+		return apierr.BadRequest(apierr.NickRequired, "nick", "nick is required")
 	}
 	if r.Channel == "" {
-		return fmt.Errorf("channel is required")
+		return apierr.BadRequest(apierr.ChannelRequired, "channel", "channel is required")
 	}
 	if len(r.Nick) < minNickLen || len(r.Nick) > maxNickLen {
-		return fmt.Errorf("nick must be between %d and %d characters long", minNickLen, maxNickLen)
+		return apierr.BadRequest(apierr.NickTooShort, "nick", fmt.Sprintf("nick must be between %d and %d characters long", minNickLen, maxNickLen))
 	}
 	if match, err := regexp.Match("^[a-zA-Z0-9_]*$", []byte(r.Nick)); !match || err != nil {
-		return fmt.Errorf("nick must contain only alphanumeric and underscores")
+		return apierr.BadRequest(apierr.NickInvalidChars, "nick", "nick must contain only alphanumeric and underscores")
 	}
 	if len(r.FullName) > defMaxLen || len(r.Email) > defMaxLen {
-		return fmt.Errorf("exceeded max field length of %d", defMaxLen)
+		return apierr.BadRequest(apierr.FieldTooLong, "", fmt.Sprintf("exceeded max field length of %d", defMaxLen))
 	}
 	if len(r.ChannelSecret) > maxChanSecretLen {
-		return fmt.Errorf("exceeded max channel secret length of %d", maxChanSecretLen)
+		return apierr.BadRequest(apierr.FieldTooLong, "channel_secret", fmt.Sprintf("exceeded max channel secret length of %d", maxChanSecretLen))
+	}
+	if r.Secret != "" && len(r.Secret) < minNickSecretLen {
+		return apierr.BadRequest(apierr.SecretTooShort, "secret", fmt.Sprintf("secret must be at least %d characters long", minNickSecretLen))
 	}
-	if r.Secret != "" && (len(r.Secret) < minNickSecretLen || len(r.Secret) > maxNickSecretLen) {
-		return fmt.Errorf("secret should be between %d and %d characters long", minNickSecretLen, maxNickSecretLen)
+	if r.Secret != "" && len(r.Secret) > maxNickSecretLen {
+		return apierr.BadRequest(apierr.InvalidSecret, "secret", fmt.Sprintf("secret must be at most %d characters long", maxNickSecretLen))
 	}
 	if match, err := regexp.Match("^[a-zA-Z0-9_]*$", []byte(r.Secret)); r.Secret != "" && !match || err != nil {
-		return fmt.Errorf("secret must contain only alphanumeric and underscores")
+		return apierr.BadRequest(apierr.InvalidSecret, "secret", "secret must contain only alphanumeric and underscores")
 	}
 	return nil
 }
 
 func (api *API) registerNick(c context.Context, w http.ResponseWriter, req *registerNickReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
 	ch, err := api.store.Get(req.Channel)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch channel")
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.ChannelNotFound, "channel", "could not fetch channel"))
+		return nil, nil
 	}
 
 	if ch.Secret != req.ChannelSecret {
-		return nil, fmt.Errorf("invalid secret")
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "channel_secret", "invalid secret"))
+		return nil, nil
 	}
 
 	secret, err := ch.Register(&User{
@@ -155,16 +218,20 @@ func (api *API) registerNick(c context.Context, w http.ResponseWriter, req *regi
 	}, req.Secret)
 
 	if err != nil {
-		return nil, err
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "secret", err.Error()))
+		return nil, nil
 	}
 
+	userID := api.stampUserID(ch, req.Nick, secret)
+
 	// TODO - Need transaction
 	err = api.store.Save(ch)
 	if err != nil {
-		return nil, fmt.Errorf("could not update channel membership")
+		apierr.WriteJSON(w, apierr.New(apierr.ChannelCreateFailed, http.StatusInternalServerError, "", "could not update channel membership"))
+		return nil, nil
 	}
 
-	return h.NewResponse(registerNickResp{Secret: secret}, http.StatusOK), nil
+	return h.NewResponse(registerNickResp{Secret: secret, UserID: userID}, http.StatusOK), nil
 }
 
 type unreadCountReq struct {
@@ -174,21 +241,25 @@ type unreadCountReq struct {
 
 func (r *unreadCountReq) Validate() error {
 	if r.Nick == "" {
-		return fmt.Errorf("nick is required")
+		return apierr.BadRequest(apierr.NickRequired, "nick", "nick is required")
 	}
 	if len(r.Nick) < minNickLen || len(r.Nick) > maxNickLen {
-		return fmt.Errorf("nick must be between %d and %d characters long", minNickLen, maxNickLen)
+		return apierr.BadRequest(apierr.NickTooShort, "nick", fmt.Sprintf("nick must be between %d and %d characters long", minNickLen, maxNickLen))
 	}
 	if r.Channel == "" {
-		return fmt.Errorf("channel is required")
+		return apierr.BadRequest(apierr.ChannelRequired, "channel", "channel is required")
 	}
 	if len(r.Channel) > maxChanNameLen {
-		return fmt.Errorf("channel name must not exceed %d characters", maxChanNameLen)
+		return apierr.BadRequest(apierr.ChannelNameInvalid, "channel", fmt.Sprintf("channel name must not exceed %d characters", maxChanNameLen))
 	}
 	return nil
 }
 
 func (api *API) unreadCount(c context.Context, w http.ResponseWriter, req *unreadCountReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
 	return h.NewResponse(api.store.GetUnreadCount(req.Nick, req.Channel), http.StatusOK), nil
 }
 
@@ -199,21 +270,26 @@ type channelMembersReq struct {
 
 func (r *channelMembersReq) Validate() error {
 	if r.Channel == "" {
-		return fmt.Errorf("channel is required")
+		return apierr.BadRequest(apierr.ChannelRequired, "channel", "channel is required")
 	}
 	if len(r.Channel) > maxChanNameLen {
-		return fmt.Errorf("channel name must not exceed %d characters", maxChanNameLen)
+		return apierr.BadRequest(apierr.ChannelNameInvalid, "channel", fmt.Sprintf("channel name must not exceed %d characters", maxChanNameLen))
 	}
 	if len(r.ChannelSecret) > maxChanSecretLen {
-		return fmt.Errorf("channel_secret must not exceed %d characters", maxChanSecretLen)
+		return apierr.BadRequest(apierr.FieldTooLong, "channel_secret", fmt.Sprintf("channel_secret must not exceed %d characters", maxChanSecretLen))
 	}
 	return nil
 }
 
 func (api *API) channelMembers(c context.Context, w http.ResponseWriter, req *channelMembersReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
 	ch, err := api.store.Get(req.Channel)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch channel")
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.ChannelNotFound, "channel", "could not fetch channel"))
+		return nil, nil
 	}
 
 	members := []User{}
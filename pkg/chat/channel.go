@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+const (
+	defNickSecretLen = 10
+	defChanSecretLen = 32
+
+	secretAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Chat represents a single channel: its membership and the secrets
+// needed to join or administer it.
+type Chat struct {
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+	Secret  string `json:"-"`
+	Members []User `json:"members,omitempty"`
+}
+
+// User represents a registered channel member.
+type User struct {
+	Nick     string `json:"nick"`
+	FullName string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Secret   string `json:"-"`
+
+	// FirstSecretHash is the sha256 hash of the secret the nick first
+	// registered with. It is set once and never updated, so it can
+	// serve as a stable derivation key for UserID across secret
+	// rotations (see UserIDCalculator).
+	FirstSecretHash string `json:"-"`
+
+	// UserID is derived once from (Nick, FirstSecretHash) and cached
+	// here so it survives nick secret rotation unchanged.
+	UserID string `json:"user_id,omitempty"`
+}
+
+// NewChannel creates a new, empty channel with a freshly generated
+// admin secret.
+func NewChannel(name string, private bool) *Chat {
+	secret, err := generateSecret(defChanSecretLen)
+	if err != nil {
+		secret = ""
+	}
+	return &Chat{
+		Name:    name,
+		Private: private,
+		Secret:  secret,
+	}
+}
+
+// Register adds u as a member of ch, using secret as their nick
+// secret if provided or generating one otherwise, and returns the
+// secret that ended up being used.
+func (ch *Chat) Register(u *User, secret string) (string, error) {
+	for _, m := range ch.Members {
+		if m.Nick == u.Nick {
+			return "", fmt.Errorf("nick %q is already registered", u.Nick)
+		}
+	}
+
+	if secret == "" {
+		s, err := generateSecret(defNickSecretLen)
+		if err != nil {
+			return "", fmt.Errorf("could not generate nick secret: %v", err)
+		}
+		secret = s
+	}
+
+	u.Secret = secret
+	ch.Members = append(ch.Members, *u)
+
+	return secret, nil
+}
+
+// RotateUserSecret issues a new secret for the member registered under
+// nick, provided currentSecret matches what they hold today. It only
+// ever updates the mutable Secret field - FirstSecretHash (and
+// therefore UserID) is left untouched.
+func (ch *Chat) RotateUserSecret(nick, currentSecret string) (string, error) {
+	for i := range ch.Members {
+		if ch.Members[i].Nick != nick {
+			continue
+		}
+
+		if ch.Members[i].Secret != currentSecret {
+			return "", fmt.Errorf("invalid secret")
+		}
+
+		newSecret, err := generateSecret(defNickSecretLen)
+		if err != nil {
+			return "", fmt.Errorf("could not generate nick secret: %v", err)
+		}
+
+		ch.Members[i].Secret = newSecret
+		return newSecret, nil
+	}
+
+	return "", fmt.Errorf("nick %q is not registered", nick)
+}
+
+// generateSecret returns a random alphanumeric string of length n,
+// matching the ^[a-zA-Z0-9_]*$ pattern required of nick/channel
+// secrets.
+func generateSecret(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = secretAlphabet[int(b)%len(secretAlphabet)]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	h "github.com/tonto/kit/http"
+
+	"github.com/tonto/gossip/pkg/apierr"
+	"github.com/tonto/gossip/pkg/broker"
+)
+
+const (
+	maxHistoryLimit = 100
+
+	dirForward = "forward"
+	dirReverse = "reverse"
+)
+
+// HistoryOpts controls a single scrollback query against a channel's
+// message log.
+type HistoryOpts struct {
+	Limit     int
+	Cursor    string
+	Direction string
+}
+
+// EncodeCursor packs a sequence number and direction into an opaque,
+// URL-safe cursor token. It is exported so Store implementations
+// (necessarily outside this package) can produce cursors for
+// History's nextCursor/prevCursor without reimplementing the
+// base64(seq|direction) scheme themselves.
+func EncodeCursor(seq uint64, direction string) string {
+	raw := fmt.Sprintf("%d|%s", seq, direction)
+	return base64.URLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor reverses EncodeCursor, returning an *apierr.APIError if
+// the cursor was tampered with or malformed. Store implementations
+// should use this rather than parsing cursors themselves, so decoding
+// stays in lockstep with the validation already done in
+// channelHistoryReq.Validate.
+func DecodeCursor(cursor string) (seq uint64, direction string, err error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", apierr.BadRequest(apierr.InvalidCursor, "cursor", "not valid base64")
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return 0, "", apierr.BadRequest(apierr.InvalidCursor, "cursor", "malformed cursor")
+	}
+
+	seq, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", apierr.BadRequest(apierr.InvalidCursor, "cursor", "malformed sequence")
+	}
+
+	if parts[1] != dirForward && parts[1] != dirReverse {
+		return 0, "", apierr.BadRequest(apierr.InvalidCursor, "cursor", "unknown direction")
+	}
+
+	return seq, parts[1], nil
+}
+
+type channelHistoryReq struct {
+	Channel       string `json:"channel"`
+	ChannelSecret string `json:"channel_secret"`
+	Limit         int    `json:"limit"`
+	Cursor        string `json:"cursor"`
+	Direction     string `json:"direction"`
+}
+
+func (r *channelHistoryReq) Validate() error {
+	if r.Channel == "" {
+		return apierr.BadRequest(apierr.ChannelRequired, "channel", "channel is required")
+	}
+	if len(r.Channel) > maxChanNameLen {
+		return apierr.BadRequest(apierr.ChannelNameInvalid, "channel", fmt.Sprintf("channel name must not exceed %d characters", maxChanNameLen))
+	}
+	if len(r.ChannelSecret) > maxChanSecretLen {
+		return apierr.BadRequest(apierr.FieldTooLong, "channel_secret", fmt.Sprintf("channel_secret must not exceed %d characters", maxChanSecretLen))
+	}
+	if r.Limit < 0 || r.Limit > maxHistoryLimit {
+		return apierr.BadRequest(apierr.InvalidLimit, "limit", fmt.Sprintf("must be between 0 and %d", maxHistoryLimit))
+	}
+	if r.Direction != "" && r.Direction != dirForward && r.Direction != dirReverse {
+		return apierr.BadRequest(apierr.InvalidDirection, "direction", "must be forward or reverse")
+	}
+	if r.Cursor != "" {
+		if _, _, err := DecodeCursor(r.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type channelHistoryResp struct {
+	Messages   []broker.Msg `json:"messages"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
+}
+
+// channelHistory returns a page of a channel's message log using opaque
+// forward/reverse cursors, so clients can scroll back without loading the
+// whole read model kept by listChannels/channelMembers.
+func (api *API) channelHistory(c context.Context, w http.ResponseWriter, req *channelHistoryReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
+	ch, err := api.store.Get(req.Channel)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.ChannelNotFound, "channel", "could not fetch channel"))
+		return nil, nil
+	}
+
+	if ch.Secret != req.ChannelSecret {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "channel_secret", "invalid secret"))
+		return nil, nil
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defMaxLen
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	direction := req.Direction
+	if direction == "" {
+		direction = dirReverse
+	}
+
+	next, prev, msgs, err := api.store.History(req.Channel, HistoryOpts{
+		Limit:     limit,
+		Cursor:    req.Cursor,
+		Direction: direction,
+	})
+	if err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.HistoryUnavailable, http.StatusInternalServerError, "", "could not fetch history"))
+		return nil, nil
+	}
+
+	return h.NewResponse(channelHistoryResp{
+		Messages:   msgs,
+		NextCursor: next,
+		PrevCursor: prev,
+	}, http.StatusOK), nil
+}
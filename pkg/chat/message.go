@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	h "github.com/tonto/kit/http"
+
+	"github.com/tonto/gossip/pkg/apierr"
+	"github.com/tonto/gossip/pkg/broker"
+)
+
+const maxMessageLen = 2000
+
+type sendMessageReq struct {
+	Channel       string `json:"channel"`
+	ChannelSecret string `json:"channel_secret"`
+	Nick          string `json:"nick"`
+	Secret        string `json:"secret"`
+	Text          string `json:"text"`
+}
+
+func (r *sendMessageReq) Validate() error {
+	if r.Channel == "" {
+		return apierr.BadRequest(apierr.ChannelRequired, "channel", "channel is required")
+	}
+	if r.Nick == "" {
+		return apierr.BadRequest(apierr.NickRequired, "nick", "nick is required")
+	}
+	if r.Text == "" {
+		return apierr.BadRequest(apierr.MessageRequired, "text", "text is required")
+	}
+	if len(r.Text) > maxMessageLen {
+		return apierr.BadRequest(apierr.FieldTooLong, "text", fmt.Sprintf("text must not exceed %d characters", maxMessageLen))
+	}
+	return nil
+}
+
+type sendMessageResp struct {
+	UserID string `json:"user_id"`
+}
+
+// sendMessage publishes a user-authored chat message onto the
+// channel's ingest subject, the same subject channelWebhook publishes
+// normalized alerts to. Unlike a webhook payload, a chat message is
+// attributable to a registered nick, so it is stamped with the
+// sender's UserID (derived once from their first-registered secret)
+// before publishing - this is what lets ingest.Ingest's read model
+// index history by author across nick changes and secret rotations.
+func (api *API) sendMessage(c context.Context, w http.ResponseWriter, req *sendMessageReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
+	ch, err := api.store.Get(req.Channel)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.ChannelNotFound, "channel", "could not fetch channel"))
+		return nil, nil
+	}
+
+	if ch.Secret != req.ChannelSecret {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "channel_secret", "invalid secret"))
+		return nil, nil
+	}
+
+	var member *User
+	for i := range ch.Members {
+		if ch.Members[i].Nick == req.Nick {
+			member = &ch.Members[i]
+			break
+		}
+	}
+	if member == nil || member.Secret != req.Secret {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "secret", "invalid secret"))
+		return nil, nil
+	}
+
+	userID := api.stampUserID(ch, req.Nick, req.Secret)
+	if err := api.store.Save(ch); err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.ChannelCreateFailed, http.StatusInternalServerError, "", "could not update channel membership"))
+		return nil, nil
+	}
+
+	msg := &broker.Msg{From: req.Nick, Text: req.Text, Time: time.Now(), UserID: userID}
+
+	data, err := broker.EncodeMsg(msg)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.MessagePublishFailed, http.StatusInternalServerError, "", "could not encode message"))
+		return nil, nil
+	}
+
+	if err := api.pub.Publish("chat."+req.Channel, data); err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.MessagePublishFailed, http.StatusInternalServerError, "", "could not publish message"))
+		return nil, nil
+	}
+
+	return h.NewResponse(sendMessageResp{UserID: userID}, http.StatusOK), nil
+}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	h "github.com/tonto/kit/http"
+	"github.com/tonto/kit/http/respond"
+
+	"github.com/tonto/gossip/pkg/pow"
+)
+
+var errPowRequired = fmt.Errorf("pow: valid proof-of-work solution required")
+
+// WithPoW wraps an endpoint so that it requires a solved proof-of-work
+// token, analogous to WithHTTPBasicAuth. Callers must first obtain a
+// Challenge from GET /pow/challenge and submit the solved seed/nonce
+// pair via the X-Pow-Seed and X-Pow-Nonce headers.
+func WithPoW(mgr *pow.Manager, difficulty int) h.Option {
+	return h.WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seed := r.Header.Get("X-Pow-Seed")
+			nonce := r.Header.Get("X-Pow-Nonce")
+
+			if seed == "" || nonce == "" || !mgr.Verify(seed, nonce, difficulty) {
+				respond.WithJSON(
+					w, r,
+					h.NewError(http.StatusTooManyRequests, errPowRequired),
+				)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// powChallengeHandler issues a new proof-of-work challenge at a fixed
+// difficulty. It is registered directly via RegisterHandler since it
+// takes no request body.
+func powChallengeHandler(mgr *pow.Manager, difficulty int) func(context.Context, http.ResponseWriter, *http.Request) {
+	return func(c context.Context, w http.ResponseWriter, r *http.Request) {
+		ch, err := mgr.Issue(difficulty)
+		if err != nil {
+			respond.WithJSON(
+				w, r,
+				h.NewError(http.StatusInternalServerError, err),
+			)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ch)
+	}
+}
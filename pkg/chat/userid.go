@@ -0,0 +1,130 @@
+package chat
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	h "github.com/tonto/kit/http"
+
+	"github.com/tonto/gossip/pkg/apierr"
+)
+
+// UserIDCalculator derives a stable UserID for a user from their nick
+// and first-registered secret, keyed with a server-side pepper. Nicks
+// are mutable and can be re-registered once a channel membership is
+// gone, so messages need an author identifier that survives both nick
+// changes and secret rotation.
+type UserIDCalculator struct {
+	pepper []byte
+}
+
+// NewUserIDCalculator creates a UserIDCalculator using pepper as the
+// HMAC key. pepper should be a long, random, server-side value loaded
+// once at startup; rotating it invalidates every previously derived
+// UserID.
+func NewUserIDCalculator(pepper string) *UserIDCalculator {
+	return &UserIDCalculator{pepper: []byte(pepper)}
+}
+
+// Calculate derives a UserID from nick and the hash of the user's
+// first-registered secret. Because firstSecretHash never changes once
+// set on a User, rotating the nick secret via /rotate_nick_secret does
+// not change the resulting UserID.
+func (c *UserIDCalculator) Calculate(nick, firstSecretHash string) string {
+	mac := hmac.New(sha256.New, c.pepper)
+	mac.Write([]byte(nick + "|" + firstSecretHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func secretHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// stampUserID ensures the member matching nick in ch has a
+// FirstSecretHash and UserID set, deriving them from secret on first
+// call and leaving them untouched on subsequent calls (e.g. after a
+// secret rotation), and returns the resulting UserID.
+func (api *API) stampUserID(ch *Chat, nick, secret string) string {
+	for i := range ch.Members {
+		if ch.Members[i].Nick != nick {
+			continue
+		}
+		if ch.Members[i].FirstSecretHash == "" {
+			ch.Members[i].FirstSecretHash = secretHash(secret)
+		}
+		if ch.Members[i].UserID == "" {
+			ch.Members[i].UserID = api.userIDs.Calculate(nick, ch.Members[i].FirstSecretHash)
+		}
+		return ch.Members[i].UserID
+	}
+	return ""
+}
+
+type rotateNickSecretReq struct {
+	Channel       string `json:"channel"`
+	ChannelSecret string `json:"channel_secret"`
+	Nick          string `json:"nick"`
+	Secret        string `json:"secret"`
+}
+
+func (r *rotateNickSecretReq) Validate() error {
+	if r.Channel == "" {
+		return apierr.BadRequest(apierr.ChannelRequired, "channel", "channel is required")
+	}
+	if r.Nick == "" {
+		return apierr.BadRequest(apierr.NickRequired, "nick", "nick is required")
+	}
+	if r.Secret == "" {
+		return apierr.BadRequest(apierr.SecretTooShort, "secret", "secret is required")
+	}
+	if len(r.ChannelSecret) > maxChanSecretLen {
+		return apierr.BadRequest(apierr.FieldTooLong, "channel_secret", fmt.Sprintf("exceeded max channel secret length of %d", maxChanSecretLen))
+	}
+	return nil
+}
+
+type rotateNickSecretResp struct {
+	Secret string `json:"secret"`
+	UserID string `json:"user_id"`
+}
+
+// rotateNickSecret issues a new nick secret for a user who still holds
+// the current one, without changing their UserID: the derivation key
+// is the hash of the *first* secret the nick ever registered, which
+// rotation does not touch.
+func (api *API) rotateNickSecret(c context.Context, w http.ResponseWriter, req *rotateNickSecretReq) (*h.Response, error) {
+	if !checkRequest(w, req) {
+		return nil, nil
+	}
+
+	ch, err := api.store.Get(req.Channel)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.ChannelNotFound, "channel", "could not fetch channel"))
+		return nil, nil
+	}
+
+	if ch.Secret != req.ChannelSecret {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "channel_secret", "invalid secret"))
+		return nil, nil
+	}
+
+	newSecret, err := ch.RotateUserSecret(req.Nick, req.Secret)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.InvalidSecret, "secret", err.Error()))
+		return nil, nil
+	}
+
+	userID := api.stampUserID(ch, req.Nick, newSecret)
+
+	if err := api.store.Save(ch); err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.ChannelCreateFailed, http.StatusInternalServerError, "", "could not update channel membership"))
+		return nil, nil
+	}
+
+	return h.NewResponse(rotateNickSecretResp{Secret: newSecret, UserID: userID}, http.StatusOK), nil
+}
@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"net/http"
+
+	"github.com/tonto/gossip/pkg/apierr"
+)
+
+// validator is implemented by every request type in this package.
+type validator interface {
+	Validate() error
+}
+
+// checkRequest runs req.Validate() and, on failure, writes it to w as
+// the apierr JSON envelope, returning false so the caller can bail
+// out. It is called explicitly at the top of every Endpoint handler
+// rather than relying solely on the tonto/kit framework's own
+// Validate()-rejection path: kit has no knowledge of *apierr.APIError
+// and would otherwise stringify it, silently dropping Code and
+// Highlight before the client ever sees them - precisely the inline
+// validation this feature exists to provide.
+func checkRequest(w http.ResponseWriter, req validator) bool {
+	err := req.Validate()
+	if err == nil {
+		return true
+	}
+
+	if aerr, ok := err.(*apierr.APIError); ok {
+		apierr.WriteJSON(w, aerr)
+		return false
+	}
+
+	apierr.WriteJSON(w, apierr.BadRequest(0, "", err.Error()))
+	return false
+}
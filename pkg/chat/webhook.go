@@ -0,0 +1,291 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	h "github.com/tonto/kit/http"
+
+	"github.com/tonto/gossip/pkg/apierr"
+	"github.com/tonto/gossip/pkg/broker"
+)
+
+const (
+	defWebhookMaxBytes = 64 * 1024
+
+	webhookBucketMax    = 30
+	webhookBucketPerSec = 1
+)
+
+// Publisher publishes a raw payload onto a subject. The chat API uses
+// it to drop normalized webhook payloads onto a channel's ingest
+// subject, where Ingest.Run picks them up like any other message.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// WebhookMapper extracts a broker.Msg out of a third-party tool's
+// alert payload.
+type WebhookMapper func(body []byte) (*broker.Msg, error)
+
+// webhookMappers is the registry of supported ?format= values for
+// POST /channel/{name}/webhook.
+var webhookMappers = map[string]WebhookMapper{
+	"kuma":         mapKumaWebhook,
+	"grafana":      mapGrafanaWebhook,
+	"alertmanager": mapAlertmanagerWebhook,
+	"generic":      mapGenericWebhook,
+}
+
+type genericWebhookPayload struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+func mapGenericWebhook(body []byte) (*broker.Msg, error) {
+	var p genericWebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("webhook: malformed generic payload: %v", err)
+	}
+	if p.From == "" || p.Text == "" {
+		return nil, fmt.Errorf("webhook: generic payload requires from and text")
+	}
+	return &broker.Msg{From: p.From, Text: p.Text, Time: time.Now()}, nil
+}
+
+// kumaTimeLayout is the "YYYY-MM-DD HH:MM:SS" format Uptime Kuma
+// stamps heartbeat.time with.
+const kumaTimeLayout = "2006-01-02 15:04:05"
+
+// parseWebhookTime parses a timestamp extracted from a third-party
+// tool's payload, trying RFC3339 (Grafana/Alertmanager's startsAt)
+// then Kuma's layout, and falls back to the receipt time if raw is
+// empty or in neither format - so a delayed or replayed webhook still
+// produces a message rather than an error.
+func parseWebhookTime(raw string) time.Time {
+	if raw == "" {
+		return time.Now()
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t
+	}
+	if t, err := time.Parse(kumaTimeLayout, raw); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+type kumaWebhookPayload struct {
+	Heartbeat struct {
+		Status int    `json:"status"`
+		Msg    string `json:"msg"`
+		Time   string `json:"time"`
+	} `json:"heartbeat"`
+	Monitor struct {
+		Name string `json:"name"`
+	} `json:"monitor"`
+}
+
+func mapKumaWebhook(body []byte) (*broker.Msg, error) {
+	var p kumaWebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("webhook: malformed kuma payload: %v", err)
+	}
+
+	severity := "up"
+	if p.Heartbeat.Status == 0 {
+		severity = "down"
+	}
+
+	return &broker.Msg{
+		From: "uptime-kuma",
+		Text: fmt.Sprintf("[%s] %s: %s", severity, p.Monitor.Name, p.Heartbeat.Msg),
+		Time: parseWebhookTime(p.Heartbeat.Time),
+	}, nil
+}
+
+type grafanaWebhookPayload struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	State   string `json:"state"`
+	Alerts  []struct {
+		StartsAt string `json:"startsAt"`
+	} `json:"alerts"`
+}
+
+func mapGrafanaWebhook(body []byte) (*broker.Msg, error) {
+	var p grafanaWebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("webhook: malformed grafana payload: %v", err)
+	}
+
+	ts := ""
+	if len(p.Alerts) > 0 {
+		ts = p.Alerts[0].StartsAt
+	}
+
+	return &broker.Msg{
+		From: "grafana",
+		Text: fmt.Sprintf("[%s] %s: %s", p.State, p.Title, p.Message),
+		Time: parseWebhookTime(ts),
+	}, nil
+}
+
+type alertmanagerWebhookPayload struct {
+	Status string `json:"status"`
+	Alerts []struct {
+		StartsAt string `json:"startsAt"`
+		Labels   struct {
+			AlertName string `json:"alertname"`
+			Severity  string `json:"severity"`
+		} `json:"labels"`
+		Annotations struct {
+			Summary string `json:"summary"`
+		} `json:"annotations"`
+	} `json:"alerts"`
+}
+
+func mapAlertmanagerWebhook(body []byte) (*broker.Msg, error) {
+	var p alertmanagerWebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("webhook: malformed alertmanager payload: %v", err)
+	}
+	if len(p.Alerts) == 0 {
+		return nil, fmt.Errorf("webhook: alertmanager payload has no alerts")
+	}
+
+	a := p.Alerts[0]
+	return &broker.Msg{
+		From: "alertmanager",
+		Text: fmt.Sprintf("[%s/%s] %s: %s", p.Status, a.Labels.Severity, a.Labels.AlertName, a.Annotations.Summary),
+		Time: parseWebhookTime(a.StartsAt),
+	}, nil
+}
+
+// tokenBucket is a minimal per-channel rate limiter for webhook
+// ingress.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	perSec float64
+	last   time.Time
+}
+
+func newTokenBucket(max, perSec float64) *tokenBucket {
+	return &tokenBucket{tokens: max, max: max, perSec: perSec, last: time.Now()}
+}
+
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.perSec
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// webhookLimiter hands out one token bucket per channel.
+type webhookLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newWebhookLimiter() *webhookLimiter {
+	return &webhookLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+func (l *webhookLimiter) allow(channel string) bool {
+	l.mu.Lock()
+	b, ok := l.buckets[channel]
+	if !ok {
+		b = newTokenBucket(webhookBucketMax, webhookBucketPerSec)
+		l.buckets[channel] = b
+	}
+	l.mu.Unlock()
+
+	return b.Allow()
+}
+
+// channelWebhook accepts normalized alert payloads from monitoring
+// tools (Uptime Kuma, Grafana Alerting, Alertmanager, or a generic
+// {"from","text"} body) and publishes them onto the channel's ingest
+// subject, so they flow through the same Ingest pipeline as websocket
+// messages. The mapper used is picked via the ?format= query param.
+func (api *API) channelWebhook(c context.Context, w http.ResponseWriter, r *http.Request) {
+	name := h.Param(c, "name")
+
+	ch, err := api.store.Get(name)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.ChannelNotFound, http.StatusNotFound, "name", "could not fetch channel"))
+		return
+	}
+
+	secret := r.Header.Get("X-Channel-Secret")
+	if secret == "" {
+		secret = r.URL.Query().Get("channel_secret")
+	}
+	if ch.Secret != secret {
+		apierr.WriteJSON(w, apierr.New(apierr.InvalidSecret, http.StatusForbidden, "channel_secret", "invalid secret"))
+		return
+	}
+
+	if !api.webhookLimiter.allow(name) {
+		apierr.WriteJSON(w, apierr.New(apierr.WebhookRateLimited, http.StatusTooManyRequests, "", fmt.Sprintf("rate limit exceeded for channel %q", name)))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "generic"
+	}
+	mapper, ok := webhookMappers[format]
+	if !ok {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.WebhookFormatUnknown, "format", fmt.Sprintf("unknown format %q", format)))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, api.webhookMaxBytes+1))
+	if err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.WebhookBodyUnreadable, http.StatusInternalServerError, "", "could not read body"))
+		return
+	}
+	if int64(len(body)) > api.webhookMaxBytes {
+		apierr.WriteJSON(w, apierr.New(apierr.WebhookPayloadTooLarge, http.StatusRequestEntityTooLarge, "", fmt.Sprintf("payload exceeds %d bytes", api.webhookMaxBytes)))
+		return
+	}
+
+	msg, err := mapper(body)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.BadRequest(apierr.WebhookMalformed, "", err.Error()))
+		return
+	}
+
+	data, err := broker.EncodeMsg(msg)
+	if err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.WebhookPublishFailed, http.StatusInternalServerError, "", "could not encode message"))
+		return
+	}
+
+	if err := api.pub.Publish("chat."+name, data); err != nil {
+		apierr.WriteJSON(w, apierr.New(apierr.WebhookPublishFailed, http.StatusInternalServerError, "", "could not publish message"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
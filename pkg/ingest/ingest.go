@@ -3,30 +3,84 @@
 package ingest
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"time"
 
 	"github.com/tonto/gossip/pkg/broker"
 )
 
-// New creates new ingest instance
-func New(mq MQ, s ChatStore) *Ingest {
-	return &Ingest{
-		mq:    mq,
-		store: s,
+const (
+	defMaxAttempts = 5
+	defBaseDelay   = 100 * time.Millisecond
+	defJitter      = 50 * time.Millisecond
+)
+
+// New creates new ingest instance. Retry behavior can be tuned via
+// Option values; sane defaults are used otherwise.
+func New(mq MQ, s ChatStore, opts ...Option) *Ingest {
+	i := &Ingest{
+		mq:          mq,
+		store:       s,
+		maxAttempts: defMaxAttempts,
+		baseDelay:   defBaseDelay,
+		jitter:      defJitter,
+	}
+
+	for _, opt := range opts {
+		opt(i)
 	}
+
+	return i
 }
 
 // Ingest represents chat ingester
 type Ingest struct {
 	mq    MQ
 	store ChatStore
+
+	maxAttempts int
+	baseDelay   time.Duration
+	jitter      time.Duration
+	metrics     Metrics
+}
+
+// Option configures an Ingest instance.
+type Option func(*Ingest)
+
+// WithMaxAttempts sets how many times AppendMessage is attempted
+// before a message is dead-lettered. Must be >= 1.
+func WithMaxAttempts(n int) Option {
+	return func(i *Ingest) { i.maxAttempts = n }
+}
+
+// WithBaseDelay sets the base delay used by the exponential backoff
+// between AppendMessage retries.
+func WithBaseDelay(d time.Duration) Option {
+	return func(i *Ingest) { i.baseDelay = d }
 }
 
-// MQ represents ingest message queue interface
+// WithJitter sets the maximum random jitter added on top of each
+// backoff delay, to avoid retry storms across channels.
+func WithJitter(d time.Duration) Option {
+	return func(i *Ingest) { i.jitter = d }
+}
+
+// WithMetrics wires a Metrics implementation so operators can observe
+// acks, retries and dead-letters, e.g. to export Prometheus counters.
+func WithMetrics(m Metrics) Option {
+	return func(i *Ingest) { i.metrics = m }
+}
+
+// MQ represents ingest message queue interface. The subscribe
+// callback returns an ack decision: a nil error acks the underlying
+// broker message, a non-nil error leaves it unacked so the broker can
+// redeliver it.
 type MQ interface {
-	SubscribeQueue(string, func(uint64, []byte)) (io.Closer, error)
+	SubscribeQueue(string, func(seq uint64, data []byte) error) (io.Closer, error)
+	Publish(subject string, data []byte) error
 }
 
 // ChatStore represents chat store interface
@@ -34,25 +88,49 @@ type ChatStore interface {
 	AppendMessage(string, *broker.Msg) error
 }
 
+// Metrics lets operators observe ingest outcomes.
+type Metrics interface {
+	// OnAck fires once a message has been durably appended to the
+	// read model and acked.
+	OnAck(channel string, seq uint64)
+	// OnRetry fires for every failed AppendMessage attempt prior to
+	// either success or dead-lettering.
+	OnRetry(channel string, seq uint64, attempt int, err error)
+	// OnDLQ fires when a message is published to the dead-letter
+	// subject, with err describing why it could not be ingested.
+	OnDLQ(channel string, seq uint64, err error)
+}
+
+// dlqEnvelope is what gets published to chat.<id>.dlq: the raw payload
+// that could not be ingested, plus why.
+type dlqEnvelope struct {
+	Payload []byte    `json:"payload"`
+	Reason  string    `json:"reason"`
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+}
+
 // Run subscribes to ingest queue group and updates chat read model
 func (i *Ingest) Run(id string) (func(), error) {
 	closer, err := i.mq.SubscribeQueue(
 		"chat."+id,
-		func(seq uint64, data []byte) {
+		func(seq uint64, data []byte) error {
 			msg, err := broker.DecodeMsg(data)
 			if err != nil {
-				msg = &broker.Msg{
-					From: "ingest",
-					Text: "ingest: message unavailable: decoding error",
-					Time: time.Now(),
-				}
+				return i.deadLetter(id, seq, data, fmt.Errorf("decoding error: %v", err))
 			}
 
 			msg.Seq = seq
 
-			// TODO - If AppendMessage or decode errors out, don't ack
-			// Ack only after persisting to store (since you are the only one that got the msg (queue subscription))
-			i.store.AppendMessage(id, msg)
+			if err := i.appendWithRetry(id, msg); err != nil {
+				return i.deadLetter(id, seq, data, err)
+			}
+
+			if i.metrics != nil {
+				i.metrics.OnAck(id, seq)
+			}
+
+			return nil
 		},
 	)
 
@@ -62,3 +140,62 @@ func (i *Ingest) Run(id string) (func(), error) {
 
 	return func() { closer.Close() }, nil
 }
+
+// appendWithRetry retries store.AppendMessage with exponential
+// backoff, giving up after maxAttempts.
+func (i *Ingest) appendWithRetry(id string, msg *broker.Msg) error {
+	var err error
+	for attempt := 1; attempt <= i.maxAttempts; attempt++ {
+		err = i.store.AppendMessage(id, msg)
+		if err == nil {
+			return nil
+		}
+
+		if i.metrics != nil {
+			i.metrics.OnRetry(id, msg.Seq, attempt, err)
+		}
+
+		if attempt == i.maxAttempts {
+			break
+		}
+
+		time.Sleep(i.backoff(attempt))
+	}
+
+	return fmt.Errorf("ingest: giving up after %d attempts: %v", i.maxAttempts, err)
+}
+
+func (i *Ingest) backoff(attempt int) time.Duration {
+	d := i.baseDelay * time.Duration(1<<uint(attempt-1))
+	if i.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(i.jitter)))
+	}
+	return d
+}
+
+// deadLetter publishes the original payload plus failure metadata to
+// the channel's dead-letter subject. It returns nil (meaning: ack the
+// original message) once the DLQ publish succeeds, and a non-nil
+// error (meaning: don't ack, let the broker redeliver) if the DLQ
+// publish itself fails.
+func (i *Ingest) deadLetter(id string, seq uint64, payload []byte, cause error) error {
+	data, err := json.Marshal(dlqEnvelope{
+		Payload: payload,
+		Reason:  cause.Error(),
+		Seq:     seq,
+		Time:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("ingest: could not encode dlq envelope: %v", err)
+	}
+
+	if err := i.mq.Publish("chat."+id+".dlq", data); err != nil {
+		return fmt.Errorf("ingest: could not publish to dlq: %v", err)
+	}
+
+	if i.metrics != nil {
+		i.metrics.OnDLQ(id, seq, cause)
+	}
+
+	return nil
+}
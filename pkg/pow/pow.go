@@ -0,0 +1,148 @@
+// Package pow implements a lightweight proof-of-work challenge/response
+// scheme used to throttle abuse of unauthenticated endpoints (nick
+// registration, channel creation) without requiring accounts.
+package pow
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	seedBytes  = 16
+	defaultTTL = 2 * time.Minute
+	maxSeeds   = 10000
+)
+
+// Challenge is issued to a client and must be solved by finding a
+// Nonce such that sha256(Seed || Nonce) has Difficulty leading zero
+// bits.
+type Challenge struct {
+	Seed       string `json:"seed"`
+	Difficulty int    `json:"difficulty"`
+}
+
+type entry struct {
+	difficulty int
+	expires    time.Time
+}
+
+// Manager issues PoW challenges and verifies solutions, tracking
+// outstanding seeds in an expiring, size-bounded cache so a seed can
+// only ever be redeemed once.
+type Manager struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	seeds map[string]entry
+	order []string
+}
+
+// NewManager creates a Manager whose issued seeds expire after ttl. A
+// ttl <= 0 falls back to a 2 minute default.
+func NewManager(ttl time.Duration) *Manager {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Manager{
+		ttl:   ttl,
+		seeds: make(map[string]entry),
+	}
+}
+
+// Issue creates and tracks a new challenge at the given difficulty.
+func (m *Manager) Issue(difficulty int) (Challenge, error) {
+	buf := make([]byte, seedBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return Challenge{}, fmt.Errorf("pow: could not generate seed: %v", err)
+	}
+	seed := hex.EncodeToString(buf)
+
+	m.mu.Lock()
+	m.evictLocked()
+	if len(m.seeds) >= maxSeeds {
+		m.evictOldestLocked()
+	}
+	m.seeds[seed] = entry{
+		difficulty: difficulty,
+		expires:    time.Now().Add(m.ttl),
+	}
+	m.order = append(m.order, seed)
+	m.mu.Unlock()
+
+	return Challenge{Seed: seed, Difficulty: difficulty}, nil
+}
+
+// Verify checks that nonce solves the challenge previously issued for
+// seed at (at least) minDifficulty, and consumes the seed so it cannot
+// be replayed. It returns false if the seed is unknown, expired,
+// already redeemed, was issued below minDifficulty, or the nonce does
+// not meet the seed's own required difficulty. Passing the caller's
+// required difficulty here (rather than trusting whatever difficulty
+// Issue baked into the seed) lets a single Manager back multiple
+// endpoints at different difficulties without one endpoint silently
+// accepting another's easier solutions.
+func (m *Manager) Verify(seed, nonce string, minDifficulty int) bool {
+	m.mu.Lock()
+	e, ok := m.seeds[seed]
+	if ok {
+		delete(m.seeds, seed)
+	}
+	m.mu.Unlock()
+
+	if !ok || time.Now().After(e.expires) {
+		return false
+	}
+
+	if e.difficulty < minDifficulty {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(seed + nonce))
+	return leadingZeroBits(sum[:]) >= e.difficulty
+}
+
+func (m *Manager) evictLocked() {
+	now := time.Now()
+	live := m.order[:0]
+	for _, seed := range m.order {
+		e, ok := m.seeds[seed]
+		if !ok {
+			continue
+		}
+		if now.After(e.expires) {
+			delete(m.seeds, seed)
+			continue
+		}
+		live = append(live, seed)
+	}
+	m.order = live
+}
+
+func (m *Manager) evictOldestLocked() {
+	for len(m.order) > 0 && len(m.seeds) >= maxSeeds {
+		oldest := m.order[0]
+		m.order = m.order[1:]
+		delete(m.seeds, oldest)
+	}
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, by := range b {
+		if by == 0 {
+			n += 8
+			continue
+		}
+		for mask := byte(0x80); mask > 0; mask >>= 1 {
+			if by&mask != 0 {
+				return n
+			}
+			n++
+		}
+	}
+	return n
+}